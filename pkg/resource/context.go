@@ -0,0 +1,28 @@
+// Licensed to Pulumi Corporation ("Pulumi") under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// Pulumi licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import "github.com/pulumi/lumi/pkg/eval/rt"
+
+// Context carries the ambient state needed while translating a runtime object graph into serializable resources: the
+// URNs assigned so far to objects that turned out to be resources, and the SecretsManager used to encrypt any
+// Secret-marked properties before they leave this process as a checkpoint or an RPC payload (see SerializeProperties
+// and SerializeResource).  SecretsManager must never be nil; callers that don't need real encryption -- tests, local
+// development -- should set it to NoopSecretsManager{} explicitly rather than leaving it unset.
+type Context struct {
+	ObjURN         map[*rt.Object]URN
+	SecretsManager SecretsManager
+}
@@ -0,0 +1,293 @@
+// Licensed to Pulumi Corporation ("Pulumi") under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// Pulumi licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+// PropertyPath is a path to a property nested within a PropertyMap, expressed as a sequence of object keys and/or
+// array indices, e.g. PropertyPath{"subnets", 0, "cidr"}.  Providers use it to tell the engine which properties, if
+// changed, require the resource to be replaced rather than updated in place.
+type PropertyPath []interface{}
+
+// DiffKind categorizes how a single property's value changed between an old and a new PropertyMap.
+type DiffKind int
+
+const (
+	DiffAdd    DiffKind = iota // the property was absent in old, present in new.
+	DiffDelete                 // the property was present in old, absent in new.
+	DiffUpdate                 // the property is present in both, but its value changed.
+	DiffSame                   // the property is present in both, with an unchanged value.
+)
+
+// ValueDiff is a typed record of how a single property's value changed from old to new.  Array and Object are only
+// populated when Old and New are, respectively, both arrays or both objects, in which case they hold the recursive
+// per-index or per-property diff; otherwise the value is treated as an opaque scalar replacement.
+type ValueDiff struct {
+	Kind   DiffKind
+	Old    PropertyValue
+	New    PropertyValue
+	Array  *ArrayDiff  // set if Old and New are both arrays.
+	Object *ObjectDiff // set if Old and New are both objects.
+}
+
+// ArrayDiff is the result of an LCS alignment of two PropertyValue arrays: elements common to both, in order, are
+// Sames; elements that only appear in the old array are Deletes; elements that only appear in the new array are
+// Adds.  Keying by index lets callers tell an insertion or deletion in the middle of an array from a wholesale
+// rewrite of every subsequent element.
+type ArrayDiff struct {
+	Adds    map[int]PropertyValue
+	Deletes map[int]PropertyValue
+	Sames   map[int]PropertyValue
+}
+
+// Changed returns true if the array diff contains any adds or deletes.
+func (d *ArrayDiff) Changed() bool {
+	return len(d.Adds) > 0 || len(d.Deletes) > 0
+}
+
+// ObjectDiff is the result of diffing two PropertyMaps: which keys were added, deleted, updated in place, or left
+// unchanged, each as a typed ValueDiff record (Kind DiffAdd/DiffDelete/DiffUpdate/DiffSame, respectively).
+type ObjectDiff struct {
+	Adds    map[PropertyKey]ValueDiff
+	Deletes map[PropertyKey]ValueDiff
+	Sames   map[PropertyKey]ValueDiff
+	Updates map[PropertyKey]ValueDiff
+}
+
+// Changed returns true if the object diff contains any adds, deletes, or updates.
+func (d *ObjectDiff) Changed() bool {
+	return len(d.Adds) > 0 || len(d.Deletes) > 0 || len(d.Updates) > 0
+}
+
+// Diff computes a structured, typed difference between an old and a new PropertyMap.  Scalars are compared for
+// equality; arrays are aligned with an LCS so in-order insertions and deletions don't look like a full rewrite;
+// objects are diffed recursively.  Computed and output sentinels never compare equal to a concrete value; two
+// computed sentinels compare equal only when their sets of dependent URNs match exactly.  Key iteration, including
+// for the nested object diffs, follows the same Stable order that cloneObjectProperties uses, so two calls to Diff
+// over equivalent maps always produce identically-ordered results.
+func Diff(old, new PropertyMap) *ObjectDiff {
+	diff := &ObjectDiff{
+		Adds:    make(map[PropertyKey]ValueDiff),
+		Deletes: make(map[PropertyKey]ValueDiff),
+		Sames:   make(map[PropertyKey]ValueDiff),
+		Updates: make(map[PropertyKey]ValueDiff),
+	}
+
+	merged := make(PropertyMap)
+	for k, v := range old {
+		merged[k] = v
+	}
+	for k, v := range new {
+		merged[k] = v
+	}
+
+	for _, k := range merged.Stable() {
+		ov, hasOld := old[k]
+		nv, hasNew := new[k]
+		switch {
+		case hasOld && !hasNew:
+			diff.Deletes[k] = ValueDiff{Kind: DiffDelete, Old: ov}
+		case !hasOld && hasNew:
+			diff.Adds[k] = ValueDiff{Kind: DiffAdd, New: nv}
+		default:
+			if vd, changed := diffValue(ov, nv); changed {
+				diff.Updates[k] = vd
+			} else {
+				diff.Sames[k] = ValueDiff{Kind: DiffSame, Old: ov, New: nv}
+			}
+		}
+	}
+
+	return diff
+}
+
+// diffValue computes the typed diff between two individual PropertyValues; changed is false if they are equivalent,
+// taking the special computed/output equality rules into account.
+func diffValue(old, new PropertyValue) (ValueDiff, bool) {
+	if old.IsSecret() || new.IsSecret() {
+		// Unwrap to the plaintext before comparing; two secrets (or a secret and a plain value holding the same
+		// data) are equal iff their decrypted plaintexts match.  The resulting ValueDiff still carries the original,
+		// wrapped Old/New so callers never lose the fact that a changed property was secret.
+		op, np := old, new
+		if old.IsSecret() {
+			op = old.SecretValue()
+		}
+		if new.IsSecret() {
+			np = new.SecretValue()
+		}
+		if vd, changed := diffValue(op, np); changed {
+			return ValueDiff{Kind: DiffUpdate, Old: old, New: new, Array: vd.Array, Object: vd.Object}, true
+		}
+		return ValueDiff{}, false
+	}
+
+	if old.IsComputed() || new.IsComputed() {
+		if old.IsComputed() && new.IsComputed() && sameURNs(old.ComputedSources(), new.ComputedSources()) {
+			return ValueDiff{}, false
+		}
+		return ValueDiff{Kind: DiffUpdate, Old: old, New: new}, true
+	}
+
+	if old.IsOutput() || new.IsOutput() {
+		// Outputs stand in for values that will only be known once the provider runs; they are never considered
+		// equal to anything, including another output, so any occurrence forces an update.
+		return ValueDiff{Kind: DiffUpdate, Old: old, New: new}, true
+	}
+
+	if old.IsArray() && new.IsArray() {
+		if ad := diffArray(old.ArrayValue(), new.ArrayValue()); ad.Changed() {
+			return ValueDiff{Kind: DiffUpdate, Old: old, New: new, Array: ad}, true
+		}
+		return ValueDiff{}, false
+	}
+
+	if old.IsObject() && new.IsObject() {
+		if od := Diff(old.ObjectValue(), new.ObjectValue()); od.Changed() {
+			return ValueDiff{Kind: DiffUpdate, Old: old, New: new, Object: od}, true
+		}
+		return ValueDiff{}, false
+	}
+
+	if old.DeepEquals(new) {
+		return ValueDiff{}, false
+	}
+	return ValueDiff{Kind: DiffUpdate, Old: old, New: new}, true
+}
+
+// diffArray aligns old and new with a longest-common-subsequence table and walks the result to classify each index.
+func diffArray(old, new []PropertyValue) *ArrayDiff {
+	ad := &ArrayDiff{
+		Adds:    make(map[int]PropertyValue),
+		Deletes: make(map[int]PropertyValue),
+		Sames:   make(map[int]PropertyValue),
+	}
+
+	n, m := len(old), len(new)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if old[i].DeepEquals(new[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case old[i].DeepEquals(new[j]):
+			ad.Sames[j] = new[j]
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ad.Deletes[i] = old[i]
+			i++
+		default:
+			ad.Adds[j] = new[j]
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ad.Deletes[i] = old[i]
+	}
+	for ; j < m; j++ {
+		ad.Adds[j] = new[j]
+	}
+
+	return ad
+}
+
+// sameURNs returns true if a and b contain the same set of URNs, irrespective of order or duplicates.
+func sameURNs(a, b []URN) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[URN]bool, len(a))
+	for _, u := range a {
+		set[u] = true
+	}
+	for _, u := range b {
+		if !set[u] {
+			return false
+		}
+	}
+	return true
+}
+
+// ReplacementRequired reports whether, given a provider-supplied set of replacement-triggering property paths, at
+// least one of those paths names a property that actually changed in this diff.  Engine code uses this to choose
+// between an in-place update and a create-before-delete replacement.
+func (d *ObjectDiff) ReplacementRequired(paths []PropertyPath) bool {
+	for _, path := range paths {
+		if d.pathChanged(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathChanged reports whether the property named by path changed anywhere within this (possibly nested) diff.
+func (d *ObjectDiff) pathChanged(path PropertyPath) bool {
+	if len(path) == 0 {
+		return d.Changed()
+	}
+
+	key, ok := path[0].(string)
+	if !ok {
+		return false
+	}
+	k := PropertyKey(key)
+	rest := path[1:]
+
+	if _, has := d.Adds[k]; has {
+		return true
+	}
+	if _, has := d.Deletes[k]; has {
+		return true
+	}
+	vd, has := d.Updates[k]
+	if !has {
+		return false
+	}
+	if len(rest) == 0 {
+		return true
+	}
+
+	if vd.Object != nil {
+		return vd.Object.pathChanged(rest)
+	}
+	if vd.Array != nil {
+		// Adds and Sames are keyed by the *new* array's index, but Deletes is keyed by the *old* array's index --
+		// the two are different index spaces once an LCS alignment has shifted elements around them, so idx can
+		// only be compared against the new-index-keyed maps here.  An index that isn't in Sames is either brand new
+		// or was shifted by a nearby insertion/deletion, so treat anything but a confirmed Same conservatively as a
+		// change rather than risk missing a real replace trigger.
+		if idx, ok := rest[0].(int); ok {
+			if _, same := vd.Array.Sames[idx]; same {
+				return false
+			}
+		}
+		return true
+	}
+
+	// The update is to a scalar, but the path asked for a deeper property; treat it conservatively as a change.
+	return true
+}
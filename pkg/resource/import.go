@@ -0,0 +1,146 @@
+// Licensed to Pulumi Corporation ("Pulumi") under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// Pulumi licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"github.com/pulumi/lumi/pkg/tokens"
+	"github.com/pulumi/lumi/pkg/util/contract"
+)
+
+// Provider is the subset of a resource provider's contract needed to adopt a pre-existing, provider-managed resource
+// into a Lumi stack, rather than recreating it under Lumi's control.
+type Provider interface {
+	// Read fetches the current provider-side state of the resource identified by id, given whatever inputs the
+	// caller already knows (possibly none, for a pure import).  It returns the resource's current outputs as the
+	// provider sees them.  State indicates whether a failure, if any, left the resource's provider-side state
+	// untouched (StateOK) or in an indeterminate condition (StateUnknown).
+	Read(id ID, inputs PropertyMap) (PropertyMap, State, error)
+}
+
+// ImportResource creates a Resource that represents a pre-existing provider resource being adopted into a Lumi
+// stack, as opposed to one Lumi is about to create.  Unlike NewResource, id and urn are populated immediately --
+// the resource already exists, so there is no provider-assignment step to wait for.  Inputs and Outputs start empty;
+// AdoptOutputs fills them in once the provider's Read has run.
+func ImportResource(t tokens.Type, id ID, urn URN) Resource {
+	return &resource{
+		id:       id,
+		urn:      urn,
+		t:        t,
+		inputs:   make(PropertyMap),
+		outputs:  make(PropertyMap),
+		imported: true,
+	}
+}
+
+// AdoptOutputs populates an imported resource's outputs from a provider's Read response, and back-computes its
+// inputs by intersecting those outputs with settable, the set of properties the resource's type schema allows a
+// program to specify.  Properties absent from settable are assumed to be provider-computed and are left out of
+// Inputs, so that a later Diff doesn't treat them as properties the program forgot to specify.
+func AdoptOutputs(r Resource, outputs PropertyMap, settable []PropertyKey) {
+	contract.Requiref(r.Imported(), "r", "must have been created with ImportResource")
+
+	for k, v := range outputs {
+		r.Outputs()[k] = v
+	}
+
+	settableSet := make(map[PropertyKey]bool, len(settable))
+	for _, k := range settable {
+		settableSet[k] = true
+	}
+	for k, v := range outputs {
+		if settableSet[k] {
+			r.Inputs()[k] = v
+		}
+	}
+}
+
+// SerializedResource is the checkpoint/RPC-safe representation of a Resource: its identity, its properties (with any
+// secrets encrypted per SerializeProperties), and whether it was adopted via import rather than created.
+type SerializedResource struct {
+	ID       ID
+	URN      URN
+	Type     tokens.Type
+	Inputs   map[string]interface{}
+	Outputs  map[string]interface{}
+	Imported bool
+}
+
+// SerializeResource converts r into its checkpoint representation.  Persisting Imported here, rather than leaving it
+// as an in-memory-only bit on *resource, is what lets a later checkpoint load reconstruct it via DeserializeResource
+// and have AdoptOutputs' one-time back-computed Inputs still be recognized as intentionally partial, instead of the
+// next Diff treating every property the program didn't repeat as a delete.
+func SerializeResource(ctx *Context, r Resource) (SerializedResource, error) {
+	inputs, err := SerializeProperties(ctx, r.Inputs())
+	if err != nil {
+		return SerializedResource{}, err
+	}
+	outputs, err := SerializeProperties(ctx, r.Outputs())
+	if err != nil {
+		return SerializedResource{}, err
+	}
+	return SerializedResource{
+		ID:       r.ID(),
+		URN:      r.URN(),
+		Type:     r.Type(),
+		Inputs:   inputs,
+		Outputs:  outputs,
+		Imported: r.Imported(),
+	}, nil
+}
+
+// DeserializeResource reverses SerializeResource, reconstructing a Resource -- including its Imported bit -- from a
+// checkpoint.
+func DeserializeResource(ctx *Context, s SerializedResource) (Resource, error) {
+	inputs, err := DeserializeProperties(ctx, s.Inputs)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := DeserializeProperties(ctx, s.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	return &resource{
+		id:       s.ID,
+		urn:      s.URN,
+		t:        s.Type,
+		inputs:   inputs,
+		outputs:  outputs,
+		imported: s.Imported,
+	}, nil
+}
+
+// DiffIgnoringOmitted computes the same diff as Diff, except that a property present in old but absent from new is
+// treated as unchanged -- rather than as a delete -- whenever it appears in ignoreOmitted.  Engine code uses this
+// when diffing an imported resource's checkpointed inputs against a program's: a program that adopts a pre-existing
+// resource is not expected to repeat every provider-computed input, so a property it never mentions shouldn't look
+// like a deletion the user asked for.
+func DiffIgnoringOmitted(old, new PropertyMap, ignoreOmitted []PropertyKey) *ObjectDiff {
+	diff := Diff(old, new)
+
+	ignore := make(map[PropertyKey]bool, len(ignoreOmitted))
+	for _, k := range ignoreOmitted {
+		ignore[k] = true
+	}
+
+	for k, vd := range diff.Deletes {
+		if ignore[k] {
+			diff.Sames[k] = ValueDiff{Kind: DiffSame, Old: vd.Old, New: vd.Old}
+			delete(diff.Deletes, k)
+		}
+	}
+
+	return diff
+}
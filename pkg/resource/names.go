@@ -0,0 +1,170 @@
+// Licensed to Pulumi Corporation ("Pulumi") under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// Pulumi licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/golang/glog"
+
+	"github.com/pulumi/lumi/pkg/tokens"
+)
+
+// NameGenerator produces candidate physical names for resources created by a provider.  The default, crypto-random
+// behavior of NewUniqueHex is just one implementation; NewDeterministicNameGenerator and
+// NewCollisionCheckedNameGenerator layer in reproducibility and collision avoidance, respectively.  Providers may
+// register an alternate generator for a given resource type via RegisterNameGenerator.
+type NameGenerator interface {
+	// GenerateName returns a new candidate name with the given prefix, a random component of randlen bytes (prior to
+	// hex-encoding), and a total length capped to maxlen.
+	GenerateName(prefix string, randlen, maxlen int) (string, error)
+}
+
+// cryptoNameGenerator is the default NameGenerator; each candidate is pulled straight from crypto/rand, exactly as
+// NewUniqueHex has always done.  It never returns an error.
+type cryptoNameGenerator struct{}
+
+func (cryptoNameGenerator) GenerateName(prefix string, randlen, maxlen int) (string, error) {
+	return NewUniqueHex(prefix, randlen, maxlen), nil
+}
+
+// DefaultNameGenerator is the NameGenerator used for any resource type that hasn't registered its own.
+var DefaultNameGenerator NameGenerator = cryptoNameGenerator{}
+
+// deterministicNameGenerator draws its randomness from a PRNG seeded from the generator's seed material, rather than
+// from crypto/rand.  Every GenerateName call with the same arguments on a *fresh* generator therefore returns the
+// same candidate, which is what lets preview and up agree on a suggested physical name.  calls counts how many times
+// GenerateName has been invoked on this instance, and is folded into the per-call seed so that retries (e.g. from
+// collisionCheckedNameGenerator) draw from a genuinely different stream instead of a longer prefix of the same one.
+// calls is accessed with atomic.AddUint64 because a single instance may be shared across concurrent resource
+// registrations (e.g. wired in through RegisterNameGenerator and invoked by a parallel engine).
+type deterministicNameGenerator struct {
+	seed  uint64
+	calls uint64
+}
+
+// NewDeterministicNameGenerator returns a NameGenerator whose output is a pure function of the given resource's URN
+// and the stack it is being deployed into, so that `pulumi preview` and `pulumi up` suggest the exact same physical
+// name for that resource.
+func NewDeterministicNameGenerator(stack tokens.QName, urn URN) NameGenerator {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%v|%v", stack, urn)
+	return &deterministicNameGenerator{seed: h.Sum64()}
+}
+
+func (g *deterministicNameGenerator) GenerateName(prefix string, randlen, maxlen int) (string, error) {
+	call := atomic.AddUint64(&g.calls, 1) - 1
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%d", g.seed, call)
+
+	bs := make([]byte, randlen)
+	if _, err := rand.New(rand.NewSource(int64(h.Sum64()))).Read(bs); err != nil {
+		return "", err
+	}
+
+	str := prefix + hex.EncodeToString(bs)
+	if len(str) > maxlen {
+		str = str[:maxlen]
+	}
+	return str, nil
+}
+
+// ExistsFunc probes whether a candidate name is already in use, typically by delegating to a provider's Get/Read.  A
+// true result means the name is taken and a new candidate must be generated.
+type ExistsFunc func(name string) (bool, error)
+
+// collisionCheckedNameGenerator wraps an inner NameGenerator and rejects any candidate for which exists reports a
+// collision, retrying with an exponentially growing random suffix length until it finds a free name or runs out of
+// attempts.
+type collisionCheckedNameGenerator struct {
+	inner       NameGenerator
+	exists      ExistsFunc
+	maxAttempts int
+}
+
+// NewCollisionCheckedNameGenerator returns a NameGenerator that retries inner's suggestions against exists, doubling
+// the random suffix length (up to maxlen) on each collision, for up to maxAttempts tries before giving up.
+func NewCollisionCheckedNameGenerator(inner NameGenerator, exists ExistsFunc, maxAttempts int) NameGenerator {
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	return &collisionCheckedNameGenerator{inner: inner, exists: exists, maxAttempts: maxAttempts}
+}
+
+func (g *collisionCheckedNameGenerator) GenerateName(prefix string, randlen, maxlen int) (string, error) {
+	rl := randlen
+	for attempt := 0; attempt < g.maxAttempts; attempt++ {
+		name, err := g.inner.GenerateName(prefix, rl, maxlen)
+		if err != nil {
+			return "", err
+		}
+
+		taken, err := g.exists(name)
+		if err != nil {
+			return "", err
+		} else if !taken {
+			return name, nil
+		}
+
+		glog.V(7).Infof("Candidate name %q is already in use; retrying with a longer random suffix", name)
+		if rl*2 > maxlen {
+			rl = maxlen
+		} else {
+			rl *= 2
+		}
+	}
+	return "", fmt.Errorf("could not find a free name for prefix %q after %d attempts", prefix, g.maxAttempts)
+}
+
+// NameGeneratorFactory builds the NameGenerator to use for one specific resource, given the stack it is being
+// deployed into and its URN.  It exists, rather than a bare NameGenerator, because a deterministic generator is only
+// meaningful when seeded fresh per resource: a single cached instance would hand every resource of a registered type
+// the same seed -- and therefore the same candidate name -- instead of one keyed to that resource's own URN.
+type NameGeneratorFactory func(stack tokens.QName, urn URN) NameGenerator
+
+// defaultNameGeneratorFactory ignores stack and urn and always returns DefaultNameGenerator; it backs any resource
+// type that hasn't called RegisterNameGenerator.
+func defaultNameGeneratorFactory(stack tokens.QName, urn URN) NameGenerator {
+	return DefaultNameGenerator
+}
+
+// nameGeneratorFactories records, per resource type, the NameGeneratorFactory a provider has opted into.  Types
+// absent from the map fall back to defaultNameGeneratorFactory.
+var nameGeneratorFactories = make(map[tokens.Type]NameGeneratorFactory)
+
+// RegisterNameGenerator lets a resource provider opt a particular resource type into a non-default NameGenerator
+// (for example, a deterministic or collision-checked one), instead of the crypto-random default.  factory is called
+// fresh for every resource of type t, so a deterministic generator it returns is correctly seeded from that
+// resource's own stack and URN rather than reused across every resource of the type.
+func RegisterNameGenerator(t tokens.Type, factory NameGeneratorFactory) {
+	nameGeneratorFactories[t] = factory
+}
+
+// NameGeneratorFor returns the NameGenerator that resource type t should use for the resource identified by stack and
+// urn: the result of the NameGeneratorFactory registered for t via RegisterNameGenerator, or DefaultNameGenerator if
+// the type never registered one.
+func NameGeneratorFor(t tokens.Type, stack tokens.QName, urn URN) NameGenerator {
+	factory, has := nameGeneratorFactories[t]
+	if !has {
+		factory = defaultNameGeneratorFactory
+	}
+	return factory(stack, urn)
+}
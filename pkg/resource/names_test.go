@@ -0,0 +1,135 @@
+// Licensed to Pulumi Corporation ("Pulumi") under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// Pulumi licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pulumi/lumi/pkg/tokens"
+)
+
+func TestDeterministicNameGeneratorIsReproducible(t *testing.T) {
+	gen1 := NewDeterministicNameGenerator("my-stack", "urn:lumi:my-stack::proj::ns:type::name")
+	gen2 := NewDeterministicNameGenerator("my-stack", "urn:lumi:my-stack::proj::ns:type::name")
+
+	name1, err := gen1.GenerateName("my-", 8, 24)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	name2, err := gen2.GenerateName("my-", 8, 24)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if name1 != name2 {
+		t.Fatalf("expected two fresh generators seeded from the same URN/stack to agree, got %q and %q", name1, name2)
+	}
+}
+
+func TestDeterministicNameGeneratorRetriesDiffer(t *testing.T) {
+	gen := NewDeterministicNameGenerator("my-stack", "urn:lumi:my-stack::proj::ns:type::name")
+
+	// Before the fix, truncating to a short maxlen made every retry collapse to the same prefix of the same PRNG
+	// stream, so a collision-checked generator wrapping this one could never make progress past the first
+	// collision.  Folding the call count into the per-call seed must make consecutive candidates differ even when
+	// they're truncated down to the same length.
+	const maxlen = 10
+	seen := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		name, err := gen.GenerateName("r", 16, maxlen)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if seen[name] {
+			t.Fatalf("attempt %d produced a name already seen: %q", i, name)
+		}
+		seen[name] = true
+	}
+}
+
+func TestCollisionCheckedNameGeneratorFindsFreeNameAfterDeterministicCollision(t *testing.T) {
+	inner := NewDeterministicNameGenerator("my-stack", "urn:lumi:my-stack::proj::ns:type::name")
+
+	// Reject the very first candidate to force a retry; the bug this regresses made that retry return the exact
+	// same (rejected) candidate forever, so this would previously fail with "could not find a free name".
+	var first string
+	exists := func(name string) (bool, error) {
+		if first == "" {
+			first = name
+			return true, nil
+		}
+		return name == first, nil
+	}
+
+	gen := NewCollisionCheckedNameGenerator(inner, exists, 4)
+	name, err := gen.GenerateName("r", 8, 12)
+	if err != nil {
+		t.Fatalf("expected a free name to be found, got error: %v", err)
+	}
+	if name == first {
+		t.Fatalf("collision-checked generator returned the rejected candidate %q", name)
+	}
+}
+
+func TestNameGeneratorForUsesFactoryPerResource(t *testing.T) {
+	const ty = tokens.Type("test:index:Widget")
+	RegisterNameGenerator(ty, func(stack tokens.QName, urn URN) NameGenerator {
+		return NewDeterministicNameGenerator(stack, urn)
+	})
+
+	gen1 := NameGeneratorFor(ty, "my-stack", "urn:lumi:my-stack::proj::ns:test:index:Widget::a")
+	gen2 := NameGeneratorFor(ty, "my-stack", "urn:lumi:my-stack::proj::ns:test:index:Widget::b")
+
+	name1, err := gen1.GenerateName("w-", 8, 24)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	name2, err := gen2.GenerateName("w-", 8, 24)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Before the fix, RegisterNameGenerator cached a single instance built from whichever URN happened to be passed
+	// at registration time, so every resource of this type would collapse onto that one seed. A factory must be
+	// invoked fresh per resource so that two different URNs of the same registered type get distinct streams.
+	if name1 == name2 {
+		t.Fatalf("expected different URNs of the same registered type to get distinct names, both got %q", name1)
+	}
+}
+
+func TestNameGeneratorForFallsBackToDefault(t *testing.T) {
+	gen := NameGeneratorFor("test:index:Unregistered", "my-stack", "urn:lumi:my-stack::proj::ns:test:index:Unregistered::a")
+	if gen != DefaultNameGenerator {
+		t.Fatalf("expected an unregistered type to fall back to DefaultNameGenerator")
+	}
+}
+
+func TestDeterministicNameGeneratorConcurrentCallsDontRace(t *testing.T) {
+	gen := NewDeterministicNameGenerator("my-stack", "urn:lumi:my-stack::proj::ns:type::name")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := gen.GenerateName("r", 8, 16); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -72,6 +72,7 @@ type Resource interface {
 	SetURN(m URN)                // assignes a URN to this resource, for those under creation.
 	SetOutputsFrom(src Resource) // copy all output properties from one resource to another.
 	ShallowClone() Resource      // make a shallow clone of the resource.
+	Imported() bool              // returns true if this resource was adopted via import rather than created.
 }
 
 // State is returned when an error has occurred during a resource provider operation.  It indicates whether the
@@ -89,11 +90,12 @@ func IsResourceVertex(v *heapstate.ObjectVertex) bool {
 }
 
 type resource struct {
-	id      ID          // the resource's unique ID, assigned by the resource provider (or blank if uncreated).
-	urn     URN         // the resource's object urn, a human-friendly, unique name for the resource.
-	t       tokens.Type // the resource's type.
-	inputs  PropertyMap // the resource's input properties (as specified by the program).
-	outputs PropertyMap // the resource's output properties (as specified by the resource provider).
+	id       ID          // the resource's unique ID, assigned by the resource provider (or blank if uncreated).
+	urn      URN         // the resource's object urn, a human-friendly, unique name for the resource.
+	t        tokens.Type // the resource's type.
+	inputs   PropertyMap // the resource's input properties (as specified by the program).
+	outputs  PropertyMap // the resource's output properties (as specified by the resource provider).
+	imported bool        // true if this resource was adopted via import rather than created by Lumi.
 }
 
 func (r *resource) ID() ID               { return r.id }
@@ -102,6 +104,8 @@ func (r *resource) Type() tokens.Type    { return r.t }
 func (r *resource) Inputs() PropertyMap  { return r.inputs }
 func (r *resource) Outputs() PropertyMap { return r.outputs }
 
+func (r *resource) Imported() bool { return r.imported }
+
 func (r *resource) HasID() bool { return (string(r.id) != "") }
 func (r *resource) SetID(id ID) {
 	contract.Requiref(!r.HasID(), "id", "empty")
@@ -124,11 +128,12 @@ func (r *resource) SetOutputsFrom(src Resource) {
 // the property map is only shallowly cloned so any mutations deep within it may get reflected in the original.
 func (r *resource) ShallowClone() Resource {
 	return &resource{
-		id:      r.id,
-		urn:     r.urn,
-		t:       r.t,
-		inputs:  r.inputs.ShallowClone(),
-		outputs: r.outputs.ShallowClone(),
+		id:       r.id,
+		urn:      r.urn,
+		t:        r.t,
+		inputs:   r.inputs.ShallowClone(),
+		outputs:  r.outputs.ShallowClone(),
+		imported: r.imported,
 	}
 }
 
@@ -190,6 +195,16 @@ func cloneObjectProperty(ctx *Context, resobj *rt.Object, obj *rt.Object) (Prope
 		return NewResourceProperty(urn), true
 	}
 
+	// Serialize secret-marked properties using a Secret sentinel, so that at-rest persistence and RPC transport can
+	// encrypt them (see SerializeProperties in serialize.go) rather than ever writing the plaintext out directly.
+	if predef.IsSecretType(t) {
+		v, ok := cloneObjectProperty(ctx, resobj, obj.SecretElement())
+		if !ok {
+			return PropertyValue{}, false
+		}
+		return NewSecretProperty(v), true
+	}
+
 	// Serialize simple primitive types with their primitive equivalents.
 	switch t {
 	case types.Null:
@@ -0,0 +1,156 @@
+// Licensed to Pulumi Corporation ("Pulumi") under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// Pulumi licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// secretSig is the well-known marker Lumi uses to recognize a serialized secret envelope even from a reader with no
+// notion of secrets, so that a ciphertext blob is never mistaken for, or accidentally treated as, plaintext.
+const secretSig = "4dabf18193072939515e22adb298388d"
+
+// SecretPropertyValue is the on-the-wire envelope for a Secret PropertyValue.  A reader unaware of secrets sees only
+// an opaque object carrying the secretSig marker and a base64-encoded ciphertext blob, never the plaintext.
+type SecretPropertyValue struct {
+	Sig        string `json:"4dabf18193072939515e22adb298388d"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// String deliberately never reveals the plaintext, so that an accidental %v/%s format of a secret value -- including
+// under glog -- redacts rather than leaks.
+func (SecretPropertyValue) String() string { return "[secret]" }
+
+// SecretsManager encrypts and decrypts the plaintext carried by a Secret PropertyValue, so that checkpoints and RPC
+// payloads never carry a program's sensitive values in the clear.  It is set per-stack on resource.Context and
+// consulted whenever a PropertyMap containing secrets is serialized or deserialized.
+type SecretsManager interface {
+	// Type is a short, stable identifier for this manager's envelope format, so a later read -- possibly by a
+	// process with a different SecretsManager configuration -- can tell how the ciphertext was produced.
+	Type() string
+	// Encrypt returns the ciphertext for plaintext.
+	Encrypt(plaintext []byte) (ciphertext []byte, err error)
+	// Decrypt reverses Encrypt.
+	Decrypt(ciphertext []byte) (plaintext []byte, err error)
+}
+
+// MarshalSecret encrypts plaintext with mgr and returns the resulting wire envelope.
+func MarshalSecret(mgr SecretsManager, plaintext []byte) (SecretPropertyValue, error) {
+	ct, err := mgr.Encrypt(plaintext)
+	if err != nil {
+		return SecretPropertyValue{}, err
+	}
+	return SecretPropertyValue{Sig: secretSig, Ciphertext: base64.StdEncoding.EncodeToString(ct)}, nil
+}
+
+// UnmarshalSecret decrypts env's ciphertext with mgr and returns the original plaintext.
+func UnmarshalSecret(mgr SecretsManager, env SecretPropertyValue) ([]byte, error) {
+	ct, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("decoding secret ciphertext: %v", err)
+	}
+	return mgr.Decrypt(ct)
+}
+
+// NoopSecretsManager stores secrets as plaintext, performing no encryption at all.  It exists for local development
+// and tests; it must never be selected as a stack's default SecretsManager.
+type NoopSecretsManager struct{}
+
+func (NoopSecretsManager) Type() string                              { return "noop" }
+func (NoopSecretsManager) Encrypt(plaintext []byte) ([]byte, error)  { return plaintext, nil }
+func (NoopSecretsManager) Decrypt(ciphertext []byte) ([]byte, error) { return ciphertext, nil }
+
+// PassphraseSecretsManager derives an AES-256-GCM key from a user-supplied passphrase via PBKDF2, and uses it to
+// encrypt and decrypt secret values.  The salt is fixed per manager instance -- typically persisted alongside the
+// stack's configuration -- so that repeated encryptions of the same plaintext remain decryptable across restarts.
+type PassphraseSecretsManager struct {
+	key []byte
+}
+
+// NewPassphraseSecretsManager derives a PassphraseSecretsManager's key from passphrase and salt.  The same
+// passphrase and salt must be supplied again in order to decrypt anything this manager encrypts.
+func NewPassphraseSecretsManager(passphrase string, salt []byte) *PassphraseSecretsManager {
+	const iterations = 100000
+	const keyLen = 32 // AES-256
+	return &PassphraseSecretsManager{key: pbkdf2.Key([]byte(passphrase), salt, iterations, keyLen, sha256.New)}
+}
+
+func (m *PassphraseSecretsManager) Type() string { return "passphrase" }
+
+func (m *PassphraseSecretsManager) Encrypt(plaintext []byte) ([]byte, error) {
+	gcm, err := m.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (m *PassphraseSecretsManager) Decrypt(ciphertext []byte) ([]byte, error) {
+	gcm, err := m.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("secret ciphertext is too short to contain a nonce")
+	}
+	nonce, ct := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ct, nil)
+}
+
+func (m *PassphraseSecretsManager) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(m.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KMSClient performs the actual network calls on behalf of a KMSSecretsManager, so that this package doesn't need a
+// direct dependency on any particular cloud provider's SDK.
+type KMSClient interface {
+	Encrypt(keyID string, plaintext []byte) ([]byte, error)
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// KMSSecretsManager delegates encryption and decryption of secret values to a remote key management service.
+type KMSSecretsManager struct {
+	client KMSClient
+	keyID  string
+}
+
+// NewKMSSecretsManager returns a SecretsManager that encrypts and decrypts via client, using the given key ID.
+func NewKMSSecretsManager(client KMSClient, keyID string) *KMSSecretsManager {
+	return &KMSSecretsManager{client: client, keyID: keyID}
+}
+
+func (m *KMSSecretsManager) Type() string { return "kms:" + m.keyID }
+func (m *KMSSecretsManager) Encrypt(plaintext []byte) ([]byte, error) {
+	return m.client.Encrypt(m.keyID, plaintext)
+}
+func (m *KMSSecretsManager) Decrypt(ciphertext []byte) ([]byte, error) {
+	return m.client.Decrypt(m.keyID, ciphertext)
+}
@@ -0,0 +1,83 @@
+// Licensed to Pulumi Corporation ("Pulumi") under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// Pulumi licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testSecretsManagerRoundTrips(t *testing.T, mgr SecretsManager) {
+	plaintext := []byte("super secret value")
+
+	env, err := MarshalSecret(mgr, plaintext)
+	if err != nil {
+		t.Fatalf("MarshalSecret: %v", err)
+	}
+	if env.Sig != secretSig {
+		t.Fatalf("expected envelope Sig %q, got %q", secretSig, env.Sig)
+	}
+	if bytes.Contains([]byte(env.Ciphertext), plaintext) {
+		t.Fatalf("ciphertext %q contains the plaintext", env.Ciphertext)
+	}
+
+	got, err := UnmarshalSecret(mgr, env)
+	if err != nil {
+		t.Fatalf("UnmarshalSecret: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("expected decrypted plaintext %q, got %q", plaintext, got)
+	}
+}
+
+func TestNoopSecretsManagerRoundTrips(t *testing.T) {
+	testSecretsManagerRoundTrips(t, NoopSecretsManager{})
+}
+
+func TestPassphraseSecretsManagerRoundTrips(t *testing.T) {
+	mgr := NewPassphraseSecretsManager("correct horse battery staple", []byte("fixed-test-salt-"))
+	testSecretsManagerRoundTrips(t, mgr)
+}
+
+func TestPassphraseSecretsManagerRejectsWrongPassphrase(t *testing.T) {
+	salt := []byte("fixed-test-salt-")
+	mgr := NewPassphraseSecretsManager("correct horse battery staple", salt)
+	other := NewPassphraseSecretsManager("wrong passphrase", salt)
+
+	ct, err := mgr.Encrypt([]byte("super secret value"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if _, err := other.Decrypt(ct); err == nil {
+		t.Fatalf("expected decrypting with the wrong passphrase to fail")
+	}
+}
+
+func TestPassphraseSecretsManagerCiphertextIsNotDeterministic(t *testing.T) {
+	mgr := NewPassphraseSecretsManager("correct horse battery staple", []byte("fixed-test-salt-"))
+
+	ct1, err := mgr.Encrypt([]byte("super secret value"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	ct2, err := mgr.Encrypt([]byte("super secret value"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if bytes.Equal(ct1, ct2) {
+		t.Fatalf("expected two encryptions of the same plaintext to produce different ciphertext (distinct nonces)")
+	}
+}
@@ -0,0 +1,165 @@
+// Licensed to Pulumi Corporation ("Pulumi") under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// Pulumi licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// resourceSig is the well-known marker SerializeProperties uses to tag a serialized resource-reference property, the
+// same way secretSig tags a secret envelope.  Without it, a resource reference would serialize as a bare URN string
+// and DeserializeProperties would have no way to tell it apart from ordinary program data -- it would come back as a
+// plain string property instead of one IsResource()/ResourceValue() still recognize.
+const resourceSig = "5cf8f73096256a8f31a5e7e9a5ebd85c"
+
+// SerializeProperties converts props into a plain, JSON-able map -- the representation actually written to a
+// checkpoint or sent over RPC.  Any Secret property is replaced with its encrypted envelope via ctx.SecretsManager,
+// so a sensitive value's plaintext never reaches the serialized form, and any resource-reference property is tagged
+// with resourceSig so it can be reconstructed on the way back in.
+func SerializeProperties(ctx *Context, props PropertyMap) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, k := range props.Stable() {
+		v, err := serializePropertyValue(ctx, props[k])
+		if err != nil {
+			return nil, err
+		}
+		result[string(k)] = v
+	}
+	return result, nil
+}
+
+func serializePropertyValue(ctx *Context, v PropertyValue) (interface{}, error) {
+	if v.IsSecret() {
+		pt, err := serializePropertyValue(ctx, v.SecretValue())
+		if err != nil {
+			return nil, err
+		}
+		ptBytes, err := json.Marshal(pt)
+		if err != nil {
+			return nil, err
+		}
+		env, err := MarshalSecret(ctx.SecretsManager, ptBytes)
+		if err != nil {
+			return nil, fmt.Errorf("encrypting secret property: %v", err)
+		}
+		// Emit the envelope as a plain map, rather than the typed SecretPropertyValue, so that
+		// DeserializeProperties recognizes it identically whether it arrives in-process or after a real JSON
+		// marshal/unmarshal round trip through a checkpoint or RPC call.
+		return map[string]interface{}{
+			secretSig:    env.Sig,
+			"ciphertext": env.Ciphertext,
+		}, nil
+	}
+
+	if v.IsComputed() || v.IsOutput() {
+		return nil, fmt.Errorf("cannot serialize an unresolved computed or output property")
+	}
+
+	switch {
+	case v.IsNull():
+		return nil, nil
+	case v.IsBool():
+		return v.BoolValue(), nil
+	case v.IsNumber():
+		return v.NumberValue(), nil
+	case v.IsString():
+		return v.StringValue(), nil
+	case v.IsResource():
+		return map[string]interface{}{resourceSig: string(v.ResourceValue())}, nil
+	case v.IsArray():
+		arr := v.ArrayValue()
+		result := make([]interface{}, len(arr))
+		for i, e := range arr {
+			se, err := serializePropertyValue(ctx, e)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = se
+		}
+		return result, nil
+	case v.IsObject():
+		return SerializeProperties(ctx, v.ObjectValue())
+	}
+
+	return nil, fmt.Errorf("cannot serialize property value of unrecognized kind")
+}
+
+// DeserializeProperties is the inverse of SerializeProperties: given the plain map read back from a checkpoint or
+// received over RPC, it reconstructs a PropertyMap, decrypting any Secret envelopes it encounters via
+// ctx.SecretsManager.
+func DeserializeProperties(ctx *Context, raw map[string]interface{}) (PropertyMap, error) {
+	result := make(PropertyMap)
+	for k, v := range raw {
+		pv, err := deserializePropertyValue(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		result[PropertyKey(k)] = pv
+	}
+	return result, nil
+}
+
+func deserializePropertyValue(ctx *Context, raw interface{}) (PropertyValue, error) {
+	switch v := raw.(type) {
+	case nil:
+		return NewNullProperty(), nil
+	case bool:
+		return NewBoolProperty(v), nil
+	case float64:
+		return NewNumberProperty(v), nil
+	case string:
+		return NewStringProperty(v), nil
+	case []interface{}:
+		arr := make([]PropertyValue, len(v))
+		for i, e := range v {
+			pv, err := deserializePropertyValue(ctx, e)
+			if err != nil {
+				return PropertyValue{}, err
+			}
+			arr[i] = pv
+		}
+		return NewArrayProperty(arr), nil
+	case map[string]interface{}:
+		if sig, has := v[secretSig]; has && sig != nil {
+			sigStr, _ := sig.(string)
+			ciphertext, _ := v["ciphertext"].(string)
+			pt, err := UnmarshalSecret(ctx.SecretsManager, SecretPropertyValue{Sig: sigStr, Ciphertext: ciphertext})
+			if err != nil {
+				return PropertyValue{}, fmt.Errorf("decrypting secret property: %v", err)
+			}
+			var inner interface{}
+			if err := json.Unmarshal(pt, &inner); err != nil {
+				return PropertyValue{}, err
+			}
+			plain, err := deserializePropertyValue(ctx, inner)
+			if err != nil {
+				return PropertyValue{}, err
+			}
+			return NewSecretProperty(plain), nil
+		}
+		if urn, has := v[resourceSig]; has {
+			urnStr, _ := urn.(string)
+			return NewResourceProperty(URN(urnStr)), nil
+		}
+		obj, err := DeserializeProperties(ctx, v)
+		if err != nil {
+			return PropertyValue{}, err
+		}
+		return NewObjectProperty(obj), nil
+	}
+	return PropertyValue{}, fmt.Errorf("cannot deserialize property value of unrecognized type %T", raw)
+}